@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultChatMessagesLimit = 50
+
+func listChats(c *gin.Context) {
+	var chats []Chat
+	if err := db.Order("jid").Find(&chats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"chats": chats})
+}
+
+// listChatMessages returns a chat's messages newest-first, paginated with
+// ?before=<RFC3339 timestamp>&limit=<n>.
+func listChatMessages(c *gin.Context) {
+	chatJID := c.Param("jid")
+
+	limit := defaultChatMessagesLimit
+	if raw := c.Query("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	query := db.Where("chat_jid = ? AND deleted_at IS NULL", chatJID).Order("timestamp DESC").Limit(limit)
+	if raw := c.Query("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be an RFC3339 timestamp"})
+			return
+		}
+		query = query.Where("timestamp < ?", before)
+	}
+
+	var messages []Message
+	if err := query.Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// webhookEnvelope is the JSON shape every normalized event is wrapped in
+// before being broadcast over WebSocket or POSTed to webhooks.
+type webhookEnvelope struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// WebhookOutbox persists a webhook delivery that hasn't been confirmed yet,
+// so a crash or restart doesn't silently drop it.
+type WebhookOutbox struct {
+	ID        uint   `gorm:"primaryKey"`
+	URL       string `gorm:"not null"`
+	Payload   string `gorm:"not null;type:text"`
+	Delivered bool   `gorm:"not null;default:false"`
+	Attempts  int    `gorm:"not null;default:0"`
+	CreatedAt int64
+	LastTryAt int64
+	LastError string
+}
+
+// webhookURLs is the configurable list of endpoints every event is
+// delivered to, read once from the WEBHOOK_URLS env var (comma-separated).
+func webhookURLs() []string {
+	raw := os.Getenv("WEBHOOK_URLS")
+	if raw == "" {
+		return nil
+	}
+
+	var urls []string
+	for _, u := range strings.Split(raw, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func webhookSecret() string {
+	return os.Getenv("WEBHOOK_SECRET")
+}
+
+// webhookClient is used for outbound webhook deliveries, bounded so a slow
+// or hanging endpoint can't stall a delivery attempt indefinitely.
+var webhookClient = &http.Client{Timeout: 15 * time.Second}
+
+// dispatchEvent normalizes evt into a typed envelope, broadcasts it over
+// WebSocket, and fans it out to every configured webhook.
+func dispatchEvent(token string, evt interface{}) {
+	envelope, ok := normalizeEvent(evt)
+	if !ok {
+		return
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Error marshaling event envelope: %v", err)
+		return
+	}
+
+	broadcastRaw(token, body)
+
+	for _, url := range webhookURLs() {
+		enqueueWebhook(url, body)
+	}
+}
+
+// normalizeEvent maps a whatsmeow event to {"type": ..., "payload": ...}.
+// The second return value is false for events this bridge doesn't surface.
+func normalizeEvent(evt interface{}) (webhookEnvelope, bool) {
+	switch v := evt.(type) {
+	case *events.Receipt:
+		return webhookEnvelope{Type: "receipt", Payload: v}, true
+	case *events.Presence:
+		return webhookEnvelope{Type: "presence", Payload: v}, true
+	case *events.ChatPresence:
+		return webhookEnvelope{Type: "chat-presence", Payload: v}, true
+	case *events.HistorySync:
+		return webhookEnvelope{Type: "history-sync", Payload: v}, true
+	case *events.GroupInfo:
+		return webhookEnvelope{Type: "group-info", Payload: v}, true
+	case *events.JoinedGroup:
+		return webhookEnvelope{Type: "joined-group", Payload: v}, true
+	case *events.Connected:
+		return webhookEnvelope{Type: "connected", Payload: v}, true
+	case *events.Disconnected:
+		return webhookEnvelope{Type: "disconnected", Payload: v}, true
+	case *events.LoggedOut:
+		return webhookEnvelope{Type: "logged-out", Payload: v}, true
+	case *events.StreamReplaced:
+		return webhookEnvelope{Type: "stream-replaced", Payload: v}, true
+	case *events.PairSuccess:
+		return webhookEnvelope{Type: "pair-success", Payload: v}, true
+	default:
+		return webhookEnvelope{}, false
+	}
+}
+
+// enqueueWebhook records the delivery in webhook_outbox and makes the first
+// attempt on a worker goroutine; retryWebhookOutbox picks up anything that
+// fails here. dispatchEvent runs on whatsmeow's own event-dispatch goroutine,
+// so the delivery itself must never block that call.
+func enqueueWebhook(url string, payload []byte) {
+	row := WebhookOutbox{
+		URL:       url,
+		Payload:   string(payload),
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := db.Create(&row).Error; err != nil {
+		log.Printf("Error persisting webhook outbox row: %v", err)
+		return
+	}
+
+	go deliverWebhook(&row)
+}
+
+// deliverWebhook POSTs payload to row.URL with an HMAC-SHA256 signature,
+// marking the row delivered on success and leaving it for retry otherwise.
+func deliverWebhook(row *WebhookOutbox) {
+	sig := signPayload([]byte(row.Payload))
+
+	req, err := http.NewRequest(http.MethodPost, row.URL, bytes.NewReader([]byte(row.Payload)))
+	if err != nil {
+		recordWebhookFailure(row, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature", sig)
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		recordWebhookFailure(row, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		row.Delivered = true
+		db.Save(row)
+		return
+	}
+
+	recordWebhookFailure(row, fmt.Errorf("webhook returned status %d", resp.StatusCode))
+}
+
+func recordWebhookFailure(row *WebhookOutbox, err error) {
+	row.Attempts++
+	row.LastTryAt = time.Now().Unix()
+	row.LastError = err.Error()
+	db.Save(row)
+	log.Printf("Webhook delivery to %s failed (attempt %d): %v", row.URL, row.Attempts, err)
+}
+
+func signPayload(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret()))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryWebhookOutbox redelivers every undelivered row with exponential
+// backoff based on its attempt count, and should be run on a ticker.
+func retryWebhookOutbox() {
+	var rows []WebhookOutbox
+	if err := db.Where("delivered = ?", false).Find(&rows).Error; err != nil {
+		log.Printf("Error loading webhook outbox: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for i := range rows {
+		row := &rows[i]
+		backoff := int64(1 << uint(row.Attempts))
+		if backoff > 300 {
+			backoff = 300
+		}
+		if now-row.LastTryAt < backoff {
+			continue
+		}
+		deliverWebhook(row)
+	}
+}
+
+// startWebhookRetryLoop periodically retries undelivered webhook_outbox
+// rows so a webhook endpoint that was briefly down still gets its events.
+func startWebhookRetryLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	go func() {
+		for range ticker.C {
+			retryWebhookOutbox()
+		}
+	}()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// groupInfo is the sanitized shape every group endpoint returns, trimming
+// whatsmeow's *types.GroupInfo down to what the front end needs.
+type groupInfo struct {
+	JID          string   `json:"jid"`
+	Name         string   `json:"name"`
+	Topic        string   `json:"topic"`
+	Participants []string `json:"participants"`
+	Admins       []string `json:"admins"`
+}
+
+func toGroupInfo(g *types.GroupInfo) groupInfo {
+	info := groupInfo{
+		JID:   g.JID.String(),
+		Name:  g.Name,
+		Topic: g.Topic,
+	}
+	for _, p := range g.Participants {
+		info.Participants = append(info.Participants, p.JID.String())
+		if p.IsAdmin || p.IsSuperAdmin {
+			info.Admins = append(info.Admins, p.JID.String())
+		}
+	}
+	return info
+}
+
+type createGroupRequest struct {
+	Name         string   `json:"name"`
+	Participants []string `json:"participants"`
+}
+
+func createGroup(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	var req createGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var participants []types.JID
+	for _, raw := range req.Participants {
+		jid, ok := parseJID(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid participant JID: %s", raw)})
+			return
+		}
+		participants = append(participants, jid)
+	}
+
+	group, err := session.Client.CreateGroup(context.Background(), whatsmeow.ReqCreateGroup{
+		Name:         req.Name,
+		Participants: participants,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create group: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, toGroupInfo(group))
+}
+
+type groupParticipantsRequest struct {
+	Action       string   `json:"action"` // add, remove, promote, demote
+	Participants []string `json:"participants"`
+}
+
+func updateGroupParticipants(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	groupJID, ok := parseGroupJID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group JID"})
+		return
+	}
+
+	var req groupParticipantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	change, ok := participantChangeFor(req.Action)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of add, remove, promote, demote"})
+		return
+	}
+
+	var participants []types.JID
+	for _, raw := range req.Participants {
+		jid, ok := parseJID(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid participant JID: %s", raw)})
+			return
+		}
+		participants = append(participants, jid)
+	}
+
+	results, err := session.Client.UpdateGroupParticipants(context.Background(), groupJID, participants, change)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update participants: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func participantChangeFor(action string) (whatsmeow.ParticipantChange, bool) {
+	switch action {
+	case "add":
+		return whatsmeow.ParticipantChangeAdd, true
+	case "remove":
+		return whatsmeow.ParticipantChangeRemove, true
+	case "promote":
+		return whatsmeow.ParticipantChangePromote, true
+	case "demote":
+		return whatsmeow.ParticipantChangeDemote, true
+	default:
+		return "", false
+	}
+}
+
+func leaveGroup(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	groupJID, ok := parseGroupJID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group JID"})
+		return
+	}
+
+	if err := session.Client.LeaveGroup(context.Background(), groupJID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to leave group: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "left group"})
+}
+
+func getGroupInviteLink(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	groupJID, ok := parseGroupJID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group JID"})
+		return
+	}
+
+	link, err := session.Client.GetGroupInviteLink(context.Background(), groupJID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get invite link: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invite_link": link})
+}
+
+type joinGroupRequest struct {
+	Code string `json:"code"`
+}
+
+func joinGroupWithLink(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	var req joinGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	groupJID, err := session.Client.JoinGroupWithLink(context.Background(), req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to join group: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jid": groupJID.String()})
+}
+
+type groupSubjectRequest struct {
+	Subject string `json:"subject"`
+}
+
+func setGroupSubject(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	groupJID, ok := parseGroupJID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group JID"})
+		return
+	}
+
+	var req groupSubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := session.Client.SetGroupName(context.Background(), groupJID, req.Subject); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to set group subject: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "subject updated"})
+}
+
+type groupDescriptionRequest struct {
+	Description string `json:"description"`
+}
+
+func setGroupDescription(c *gin.Context) {
+	session := requireLoggedIn(c)
+	if session == nil {
+		return
+	}
+
+	groupJID, ok := parseGroupJID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group JID"})
+		return
+	}
+
+	var req groupDescriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := session.Client.SetGroupTopic(context.Background(), groupJID, "", "", req.Description); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to set group description: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "description updated"})
+}
+
+// requireLoggedIn resolves the caller's session and responds with 401 if it
+// hasn't completed login yet, returning nil so handlers can bail out early.
+func requireLoggedIn(c *gin.Context) *Session {
+	session := currentSession(c)
+	if session.Client.Store.ID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Device must be logged in first"})
+		return nil
+	}
+	return session
+}
+
+func parseGroupJID(c *gin.Context) (types.JID, bool) {
+	raw := c.Param("jid")
+	jid, err := types.ParseJID(raw)
+	if err != nil || jid.User == "" {
+		return jid, false
+	}
+	return jid, true
+}
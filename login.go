@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+	"go.mau.fi/whatsmeow"
+)
+
+// qrFrame is one JSON frame written to the /ws/login socket. The event
+// names mirror whatsmeow's own QR channel events (plus "error" for local
+// failures) so the front end can switch on them directly.
+type qrFrame struct {
+	Event string `json:"event"`
+	Code  string `json:"code,omitempty"`
+	JID   string `json:"jid,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleLoginWebSocket streams the full whatsmeow QR login lifecycle over a
+// WebSocket so the front end never has to poll or hit an external QR image
+// service: every "code" event carries a ready-to-render PNG data URL.
+func handleLoginWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("login websocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	session := currentSession(c)
+
+	if session.Client.Store.ID != nil {
+		_ = conn.WriteJSON(qrFrame{Event: "success", JID: session.Client.Store.ID.String()})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qrChan, err := session.Client.GetQRChannel(ctx)
+	if err != nil {
+		_ = conn.WriteJSON(qrFrame{Event: "error", Error: err.Error()})
+		return
+	}
+
+	if err := session.Client.Connect(); err != nil {
+		_ = conn.WriteJSON(qrFrame{Event: "error", Error: err.Error()})
+		return
+	}
+
+	// The WhatsApp app can only ever disconnect the socket from its side;
+	// watch for that so GetQRChannel's context gets cancelled promptly.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for evt := range qrChan {
+		frame := qrEventToFrame(session, evt)
+		if err := conn.WriteJSON(frame); err != nil {
+			cancel()
+			return
+		}
+		if frame.Event == "success" {
+			persistSession(tokenFromContext(c), frame.JID)
+		}
+	}
+}
+
+func qrEventToFrame(session *Session, evt whatsmeow.QRChannelItem) qrFrame {
+	switch evt.Event {
+	case "code":
+		dataURL, err := qrCodeDataURL(evt.Code)
+		if err != nil {
+			return qrFrame{Event: "error", Error: fmt.Sprintf("failed to render QR code: %v", err)}
+		}
+		return qrFrame{Event: "code", Code: dataURL}
+	case "timeout":
+		return qrFrame{Event: "timeout"}
+	case "success":
+		jid := ""
+		if session.Client.Store.ID != nil {
+			jid = session.Client.Store.ID.String()
+		}
+		return qrFrame{Event: "success", JID: jid}
+	case "err-unexpected-state":
+		return qrFrame{Event: "err-unexpected-state"}
+	case "err-client-outdated":
+		return qrFrame{Event: "err-client-outdated"}
+	case "err-scanned-without-multidevice":
+		return qrFrame{Event: "err-scanned-without-multidevice"}
+	default:
+		return qrFrame{Event: "error", Error: fmt.Sprintf("unrecognized QR event: %s", evt.Event)}
+	}
+}
+
+// qrCodeDataURL renders the raw QR payload as a PNG and returns it as a
+// data: URL so the front end can drop it straight into an <img> tag.
+func qrCodeDataURL(code string) (string, error) {
+	png, err := qrcode.Encode(code, qrcode.Medium, 300)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("data:image/png;base64,")
+	b.WriteString(base64.StdEncoding.EncodeToString(png))
+	return b.String(), nil
+}
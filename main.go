@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"sync"
 	"time"
 
@@ -14,50 +12,55 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq" // PostgreSQL driver
-	_ "github.com/mattn/go-sqlite3"
-	"github.com/mdp/qrterminal"
-	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
 	"google.golang.org/protobuf/proto"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-var client *whatsmeow.Client
 var db *gorm.DB
+var sessionManager *SessionManager
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins (you can customize this for security)
 	},
 }
-var wsClients = make(map[*websocket.Conn]bool)
+
+// wsClients is keyed by the API token owning the connection, so an event
+// produced by one user's session is only ever broadcast to that user's own
+// sockets instead of every socket the bridge has open.
+var wsClients = make(map[string]map[*websocket.Conn]bool)
 var wsMutex sync.Mutex
 
+// postgresDSN is shared by the gorm connection and the whatsmeow device
+// store so every bridge instance reads/writes the same session state.
+const postgresDSN = "host=100.81.120.54 port=5432 user=root password=kambin dbname=wa_lib sslmode=disable"
+
 type Request struct {
 	Recipient string `json:"recipient"`
 	Message   string `json:"message"`
+	MediaURL  string `json:"media_url"`
+	MediaData string `json:"media_data"`
+	MimeType  string `json:"mime_type"`
+	Caption   string `json:"caption"`
 }
 
-type Message struct {
-	ID        uint   `gorm:"primaryKey"`
-	Sender    string `gorm:"not null"`
-	Message   string `gorm:"not null"`
-	Timestamp string `gorm:"not null;uniqueIndex:idx_sender_message_timestamp"`
+func (r Request) hasMedia() bool {
+	return r.MediaURL != "" || r.MediaData != ""
 }
 
 func initDatabase() *gorm.DB {
-	dsn := "host=100.81.120.54 port=5432 user=root password=kambin dbname=wa_lib sslmode=disable"
 	var err error
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err = gorm.Open(postgres.Open(postgresDSN), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 	}
 
-	err = db.AutoMigrate(&Message{})
+	err = db.AutoMigrate(&Chat{}, &Contact{}, &Message{}, &UserSession{}, &WebhookOutbox{})
 	if err != nil {
 		log.Fatalf("Error migrating database: %v", err)
 	}
@@ -81,82 +84,89 @@ func sendMessage(c *gin.Context) {
 		return
 	}
 
-	// Send message
-	msg := &waProto.Message{
-		Conversation: proto.String(request.Message),
-	}
-	resp, err := client.SendMessage(context.Background(), recipientJID, msg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send message: %v", err)})
+	session := currentSession(c)
+	if session.Client.Store.ID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Device must be logged in first"})
 		return
 	}
 
-	// Handle incoming message (store in DB and broadcast)
-	handleIncomingMessage(request)
-
-	// Respond with success
-	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully", "response": resp})
-}
-
-func handleIncomingMessage(input interface{}) {
-	loc, _ := time.LoadLocation("Asia/Jakarta")
-	var sender, message, timestampUTC7 string
-
-	switch v := input.(type) {
-	case *events.Message:
-		timestampUTC7 = v.Info.Timestamp.In(loc).String()
-		sender = v.Info.Sender.String()
-		message = v.Message.GetConversation()
-
-	case Request:
-		timestampUTC7 = time.Now().In(loc).String()
-		sender = "6285123945816@s.whatsapp.net"
-		message = v.Message
-		log.Printf("Sender: %s, Message: %s", sender, message)
+	// Build either a plain text message or, if media fields were supplied,
+	// an uploaded image/document/audio/video message.
+	var msg *waProto.Message
+	var mediaPath string
+	if request.hasMedia() {
+		data, err := decodeMediaData(request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	default:
-		log.Printf("Unsupported input type: %T", input)
-		return
-	}
+		msg, err = buildMediaMessage(context.Background(), session.Client, data, request.MimeType, request.Caption)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	msg := Message{
-		Sender:    sender,
-		Message:   message,
-		Timestamp: timestampUTC7,
+		if path, err := writeMediaFile(data); err != nil {
+			log.Printf("Error saving outgoing media locally: %v", err)
+		} else {
+			mediaPath = path
+		}
+	} else {
+		msg = &waProto.Message{
+			Conversation: proto.String(request.Message),
+		}
 	}
 
-	result := db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "sender"}, {Name: "message"}, {Name: "timestamp"}},
-		UpdateAll: true,
-	}).Create(&msg)
-
-	if result.Error != nil {
-		log.Printf("Error inserting or updating message in database: %v", result.Error)
+	resp, err := session.Client.SendMessage(context.Background(), recipientJID, msg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send message: %v", err)})
 		return
 	}
 
-	broadcastMessage(map[string]string{
-		"sender":    sender,
-		"message":   message,
-		"timestamp": timestampUTC7,
-	})
+	// Record the outgoing message under the same schema incoming ones use,
+	// keyed by the ID whatsmeow just assigned it.
+	storeOutgoingMessage(session, recipientJID, resp.ID, request.Message, mediaPath)
+
+	// Respond with success
+	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully", "response": resp})
 }
 
-func broadcastMessage(message map[string]string) {
+// broadcastMessage delivers message only to WebSocket clients registered
+// under token, so a caller never sees another user's traffic.
+func broadcastMessage(token string, message map[string]string) {
 	wsMutex.Lock()
 	defer wsMutex.Unlock()
 
-	for client := range wsClients {
+	for client := range wsClients[token] {
 		err := client.WriteJSON(message)
 		if err != nil {
 			log.Printf("Error broadcasting message: %v", err)
 			client.Close()
-			delete(wsClients, client)
+			delete(wsClients[token], client)
+		}
+	}
+}
+
+// broadcastRaw writes an already-encoded JSON payload to every WebSocket
+// client registered under token, used for the typed event envelopes from
+// dispatchEvent.
+func broadcastRaw(token string, payload []byte) {
+	wsMutex.Lock()
+	defer wsMutex.Unlock()
+
+	for client := range wsClients[token] {
+		if err := client.WriteMessage(websocket.TextMessage, payload); err != nil {
+			log.Printf("Error broadcasting event: %v", err)
+			client.Close()
+			delete(wsClients[token], client)
 		}
 	}
 }
 
 func handleWebSocket(c *gin.Context) {
+	token := tokenFromContext(c)
+
 	// Upgrade the HTTP connection to a WebSocket connection
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -165,9 +175,12 @@ func handleWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	// Register the new WebSocket client
+	// Register the new WebSocket client under its owning token
 	wsMutex.Lock()
-	wsClients[conn] = true
+	if wsClients[token] == nil {
+		wsClients[token] = make(map[*websocket.Conn]bool)
+	}
+	wsClients[token][conn] = true
 	wsMutex.Unlock()
 
 	// Listen for messages from the client (not used in this case)
@@ -181,102 +194,19 @@ func handleWebSocket(c *gin.Context) {
 
 	// Remove the client when the connection is closed
 	wsMutex.Lock()
-	delete(wsClients, conn)
+	delete(wsClients[token], conn)
 	wsMutex.Unlock()
 }
 
-func scanQR(c *gin.Context) {
-	// Check if the user is already logged in
-	if client.Store.ID != nil {
-		c.JSON(http.StatusOK, gin.H{"message": "Already logged in"})
-		return
-	}
-
-	// If not logged in, start the QR code process
-	qrChan, err := client.GetQRChannel(context.Background())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get QR channel"})
-		return
-	}
-
-	err = client.Connect()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to WhatsApp"})
-		return
-	}
-
-	qrCode := <-qrChan
-	switch qrCode.Event {
-	case "code":
-		// Validate QR code data
-		if qrCode.Code == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid QR code data"})
-			return
-		}
-
-		// Render QR code in the terminal using qrterminal
-		fmt.Println("Scan this QR code with your phone:")
-		qrterminal.Generate(qrCode.Code, qrterminal.L, os.Stdout)
-
-		// URL-encode the QR code data to ensure compatibility with the QR server
-		encodedQRCode := url.QueryEscape(qrCode.Code)
-
-		// Serve an HTML page with the QR code
-		html := `
-            <!DOCTYPE html>
-            <html lang="en">
-            <head>
-                <meta charset="UTF-8">
-                <meta name="viewport" content="width=device-width, initial-scale=1.0">
-                <title>Scan QR Code</title>
-                <style>
-                    body {
-                        font-family: Arial, sans-serif;
-                        text-align: center;
-                        margin-top: 50px;
-                    }
-                    img {
-                        max-width: 300px;
-                        height: auto;
-                    }
-                </style>
-            </head>
-            <body>
-                <h1>Scan the QR Code with Your Phone</h1>
-                <img src="https://api.qrserver.com/v1/create-qr-code/?size=300x300&data=%s" alt="QR Code">
-            </body>
-            </html>
-        `
-
-		// Replace the placeholder with the URL-encoded QR code data
-		c.Header("Content-Type", "text/html")
-		c.String(http.StatusOK, fmt.Sprintf(html, encodedQRCode))
-
-	case "timeout":
-		c.JSON(http.StatusRequestTimeout, gin.H{"error": "QR code timed out"})
-
-	case "login":
-		// Log the entire qrCode object to inspect its structure
-		fmt.Printf("QR Code Event: %+v\n", qrCode)
-
-		// Assuming the library automatically manages the session
-		if client.Store != nil && client.Store.ID != nil {
-			c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session data"})
-		}
-	}
-}
-
 func getGroup(c *gin.Context) {
-	// Check if the client is initialized (logged in)
-	if client == nil || client.Store.ID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Device must be scanned first"})
+	session := currentSession(c)
+	if session.Client.Store.ID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Device must be logged in first"})
 		return
 	}
 
 	// Fetch joined groups
-	groups, err := client.GetJoinedGroups()
+	groups, err := session.Client.GetJoinedGroups(context.Background())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get groups"})
 		return
@@ -305,7 +235,7 @@ func getGroup(c *gin.Context) {
 func receiveMessage(c *gin.Context) {
 	var messages []Message
 
-	result := db.Order("timestamp ASC").Find(&messages)
+	result := db.Where("deleted_at IS NULL").Order("timestamp ASC").Find(&messages)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages from database"})
 		return
@@ -314,9 +244,10 @@ func receiveMessage(c *gin.Context) {
 	var messageList []map[string]string
 	for _, msg := range messages {
 		messageList = append(messageList, map[string]string{
-			"sender":    msg.Sender,
-			"message":   msg.Message,
-			"timestamp": msg.Timestamp,
+			"sender":    msg.SenderJID,
+			"message":   msg.Body,
+			"timestamp": msg.Timestamp.Format(time.RFC3339),
+			"media_url": mediaURLFor(msg.MediaPath),
 		})
 	}
 
@@ -339,42 +270,59 @@ func parseJID(raw string) (types.JID, bool) {
 	return jid, true
 }
 
-func initClient() {
-	// Initialize database for storing WhatsApp sessions
-	container, err := sqlstore.New("sqlite3", "file:whatsapp.db?_foreign_keys=on", nil)
-	if err != nil {
-		log.Fatalf("Error initializing database: %v", err)
-	}
-	deviceStore, err := container.GetFirstDevice()
-	if err != nil {
-		log.Fatalf("Error getting device store: %v", err)
-	}
-	client = whatsmeow.NewClient(deviceStore, nil)
+// handleEvent is the shared event sink every per-token whatsmeow client is
+// wired to. It normalizes whichever event fired and forwards it to whatever
+// this bridge currently knows how to do with it.
+func handleEvent(token string, evt interface{}) {
+	session, _ := sessionManager.Get(token)
 
-	// Add event handler to capture incoming messages
-	client.AddEventHandler(func(evt interface{}) {
-		switch v := evt.(type) {
-		case *events.Message:
-			handleIncomingMessage(v)
+	switch v := evt.(type) {
+	case *events.Message:
+		var mediaPath string
+		if session != nil {
+			path, err := storeIncomingMedia(context.Background(), session.Client, v)
+			if err != nil {
+				log.Printf("Error storing incoming media: %v", err)
+			}
+			mediaPath = path
 		}
-	})
-
-	// Check if the client has an existing session
-	if client.Store.ID == nil {
-		log.Println("No existing session found. Please scan the QR code to log in.")
-	} else {
-		log.Println("Existing session found. Attempting to reconnect...")
-		err := client.Connect()
-		if err != nil {
-			log.Fatalf("Failed to reconnect: %v", err)
+		handleIncomingMessage(token, v, mediaPath)
+	case *events.PairSuccess:
+		persistSession(token, v.ID.String())
+		dispatchEvent(token, v)
+	case *events.PairError:
+		broadcastMessage(token, map[string]string{"event": "pair-error", "error": v.Error.Error()})
+	case *events.Connected:
+		if session != nil {
+			syncContacts(session)
 		}
-		log.Println("Reconnected successfully!")
+		dispatchEvent(token, v)
+	default:
+		dispatchEvent(token, v)
 	}
 }
 
+// initSessionManager opens the shared Postgres-backed device store and
+// restores any sessions that were already paired before this process
+// started, so a restart doesn't force every user to scan again.
+func initSessionManager() *SessionManager {
+	container, err := sqlstore.New(context.Background(), "postgres", postgresDSN, waLog.Stdout("Database", "INFO", true))
+	if err != nil {
+		log.Fatalf("Error initializing whatsmeow device store: %v", err)
+	}
+
+	manager := NewSessionManager(container)
+	return manager
+}
+
 func main() {
-	initClient()
 	db = initDatabase()
+	sessionManager = initSessionManager()
+	if err := sessionManager.Restore(db); err != nil {
+		log.Printf("Error restoring sessions: %v", err)
+	}
+	startWebhookRetryLoop()
+
 	router := gin.Default()
 
 	// Configure CORS middleware
@@ -385,18 +333,41 @@ func main() {
 	config.AllowCredentials = true
 
 	router.Use(cors.New(config)) // Apply CORS middleware globally
+	router.Use(authMiddleware(requireBridgeSharedSecret()))
 
 	// Routes
-	router.GET("/scan", func(c *gin.Context) {
-		scanQR(c)
-	})
 	router.POST("/send-message", sendMessage)
 	router.GET("/get-group", getGroup)
 	router.GET("/receive-message", receiveMessage)
+	router.POST("/pair-phone", pairPhone)
+	router.GET("/media/:hash", getMedia)
+	router.GET("/chats", listChats)
+	router.GET("/chats/:jid/messages", listChatMessages)
+
+	groups := router.Group("/groups")
+	{
+		groups.POST("", createGroup)
+		groups.POST("/join", joinGroupWithLink)
+		groups.POST("/:jid/participants", updateGroupParticipants)
+		groups.POST("/:jid/leave", leaveGroup)
+		groups.GET("/:jid/invite", getGroupInviteLink)
+		groups.PATCH("/:jid/subject", setGroupSubject)
+		groups.PATCH("/:jid/description", setGroupDescription)
+	}
 
 	router.GET("/ws", func(c *gin.Context) {
 		handleWebSocket(c)
 	})
+	router.GET("/ws/login", handleLoginWebSocket)
+
+	provision := router.Group("/provision/v1")
+	{
+		provision.POST("/login", provisionLogin)
+		provision.POST("/logout", provisionLogout)
+		provision.GET("/ping", provisionPing)
+		provision.DELETE("/delete_session", provisionDeleteSession)
+		provision.POST("/reconnect", provisionReconnect)
+	}
 
 	// Start server
 	router.Run(":8050")
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// mediaDir is where decrypted incoming media is written, named by its
+// SHA256 so /media/:hash can serve it back without a database round trip.
+const mediaDir = "media"
+
+func init() {
+	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+		log.Printf("Error creating media directory: %v", err)
+	}
+}
+
+// mediaTypeFor maps a MIME type to the whatsmeow upload bucket it belongs
+// to, defaulting to a generic document for anything unrecognized.
+func mediaTypeFor(mimeType string) whatsmeow.MediaType {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return whatsmeow.MediaImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return whatsmeow.MediaAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return whatsmeow.MediaVideo
+	default:
+		return whatsmeow.MediaDocument
+	}
+}
+
+// buildMediaMessage uploads raw bytes to WhatsApp's media servers and wraps
+// the resulting handle in the message type that matches mimeType's family.
+func buildMediaMessage(ctx context.Context, cli *whatsmeow.Client, data []byte, mimeType, caption string) (*waProto.Message, error) {
+	mediaType := mediaTypeFor(mimeType)
+
+	uploaded, err := cli.Upload(ctx, data, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload media: %w", err)
+	}
+
+	switch mediaType {
+	case whatsmeow.MediaImage:
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{
+			Caption:       proto.String(caption),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	case whatsmeow.MediaAudio:
+		return &waProto.Message{AudioMessage: &waProto.AudioMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	case whatsmeow.MediaVideo:
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       proto.String(caption),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	default:
+		return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+			Caption:       proto.String(caption),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	}
+}
+
+// downloadableFromMessage picks out whichever media sub-message is present
+// on an incoming *waProto.Message, if any.
+func downloadableFromMessage(msg *waProto.Message) (whatsmeow.DownloadableMessage, bool) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage(), true
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage(), true
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage(), true
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage(), true
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage(), true
+	default:
+		return nil, false
+	}
+}
+
+// storeIncomingMedia downloads and decrypts the media attached to an
+// incoming message (if any) and writes it to mediaDir named by its SHA256,
+// returning the relative path to store in Message.MediaPath.
+func storeIncomingMedia(ctx context.Context, cli *whatsmeow.Client, evt *events.Message) (string, error) {
+	downloadable, ok := downloadableFromMessage(evt.Message)
+	if !ok {
+		return "", nil
+	}
+
+	data, err := cli.Download(ctx, downloadable)
+	if err != nil {
+		return "", fmt.Errorf("failed to download media: %w", err)
+	}
+
+	return writeMediaFile(data)
+}
+
+// writeMediaFile writes data under mediaDir named by its SHA256 and returns
+// the relative path, so duplicate uploads/downloads naturally dedupe.
+func writeMediaFile(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+	path := filepath.Join(mediaDir, name)
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write media to disk: %w", err)
+	}
+
+	return path, nil
+}
+
+// getMedia streams a previously downloaded file back by its SHA256 name.
+func getMedia(c *gin.Context) {
+	hash := c.Param("hash")
+	path := filepath.Join(mediaDir, filepath.Base(hash))
+
+	f, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+		return
+	}
+	defer f.Close()
+
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, f); err != nil {
+		log.Printf("Error streaming media %s: %v", hash, err)
+	}
+}
+
+// decodeMediaData accepts either a base64 media_data payload or downloads
+// media_url, returning the raw bytes to upload.
+func decodeMediaData(request Request) ([]byte, error) {
+	if request.MediaData != "" {
+		data, err := base64.StdEncoding.DecodeString(request.MediaData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 media_data: %w", err)
+		}
+		return data, nil
+	}
+
+	return fetchPublicURL(request.MediaURL)
+}
+
+// fetchPublicURL downloads rawURL, refusing to let it resolve to a loopback,
+// link-local, or other private-network address (SSRF). The resolved address
+// is validated once and then dialed directly, so the host can't swap in a
+// different (private) address between the check and the actual connection
+// (DNS rebinding) the way a plain http.Get re-resolving at dial time would
+// allow.
+func fetchPublicURL(rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media_url: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("media_url must be http or https")
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("media_url is missing a host")
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve media_url host: %w", err)
+	}
+
+	var pinnedIP net.IP
+	for _, ip := range ips {
+		if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+			return nil, fmt.Errorf("media_url resolves to a disallowed address")
+		}
+		if pinnedIP == nil {
+			pinnedIP = ip
+		}
+	}
+
+	port := parsed.Port()
+	if port == "" {
+		if parsed.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+	pinnedAddr := net.JoinHostPort(pinnedIP.String(), port)
+
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, pinnedAddr)
+			},
+		},
+	}
+
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media_url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// mediaURLFor builds the public download URL a broadcast frame should
+// point at for a given stored MediaPath.
+func mediaURLFor(mediaPath string) string {
+	if mediaPath == "" {
+		return ""
+	}
+	return "/media/" + filepath.Base(mediaPath)
+}
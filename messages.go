@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"gorm.io/gorm/clause"
+)
+
+// handleIncomingMessage persists an inbound message and broadcasts it to
+// connected WebSocket clients. mediaPath is the relative path under
+// mediaDir for an already-downloaded attachment, if any. Edits and
+// revocations are protocol messages rather than new content, so they're
+// applied to the message they reference instead of stored as their own row.
+func handleIncomingMessage(token string, v *events.Message, mediaPath string) {
+	if protocol := v.Message.GetProtocolMessage(); protocol != nil {
+		switch protocol.GetType() {
+		case waProto.ProtocolMessage_MESSAGE_EDIT:
+			applyMessageEdit(protocol)
+		case waProto.ProtocolMessage_REVOKE:
+			applyMessageRevoke(protocol)
+		}
+		return
+	}
+
+	ensureChat(v.Info.Chat, v.Info.IsGroup)
+
+	msg := Message{
+		ID:              v.Info.ID,
+		ChatJID:         v.Info.Chat.String(),
+		SenderJID:       v.Info.Sender.String(),
+		Timestamp:       v.Info.Timestamp,
+		Body:            extractBody(v.Message),
+		MediaPath:       mediaPath,
+		QuotedMessageID: v.Message.GetExtendedTextMessage().GetContextInfo().GetStanzaID(),
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		UpdateAll: true,
+	}).Create(&msg).Error; err != nil {
+		log.Printf("Error inserting or updating message in database: %v", err)
+		return
+	}
+
+	broadcastMessage(token, map[string]string{
+		"id":        msg.ID,
+		"chat":      msg.ChatJID,
+		"sender":    msg.SenderJID,
+		"message":   msg.Body,
+		"timestamp": msg.Timestamp.Format(time.RFC3339),
+		"media_url": mediaURLFor(msg.MediaPath),
+	})
+}
+
+// storeOutgoingMessage records a message this bridge itself sent, under the
+// same schema handleIncomingMessage uses, so /chats/:jid/messages shows a
+// single merged timeline regardless of direction.
+func storeOutgoingMessage(session *Session, recipient types.JID, id, body, mediaPath string) {
+	ensureChat(recipient, recipient.Server == types.GroupServer)
+
+	msg := Message{
+		ID:        id,
+		ChatJID:   recipient.String(),
+		SenderJID: session.Client.Store.ID.String(),
+		Timestamp: time.Now(),
+		Body:      body,
+		MediaPath: mediaPath,
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		UpdateAll: true,
+	}).Create(&msg).Error; err != nil {
+		log.Printf("Error inserting outgoing message in database: %v", err)
+		return
+	}
+
+	broadcastMessage(session.Token, map[string]string{
+		"id":        msg.ID,
+		"chat":      msg.ChatJID,
+		"sender":    msg.SenderJID,
+		"message":   msg.Body,
+		"timestamp": msg.Timestamp.Format(time.RFC3339),
+		"media_url": mediaURLFor(msg.MediaPath),
+	})
+}
+
+// extractBody pulls the display text out of whichever message type carries
+// one; media messages carry it as a caption instead of a conversation.
+func extractBody(msg *waProto.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage().GetText() != "":
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage().GetCaption() != "":
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage().GetCaption() != "":
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage().GetCaption() != "":
+		return msg.GetDocumentMessage().GetCaption()
+	default:
+		return ""
+	}
+}
+
+// applyMessageEdit updates the body of the message a MESSAGE_EDIT protocol
+// message references. The row keeps its own ID, so EditOfID isn't touched
+// here; it's reserved for the case where an edit is stored as a new row.
+func applyMessageEdit(protocol *waProto.ProtocolMessage) {
+	originalID := protocol.GetKey().GetID()
+	newBody := extractBody(protocol.GetEditedMessage())
+
+	result := db.Model(&Message{}).Where("id = ?", originalID).Update("body", newBody)
+	if result.Error != nil {
+		log.Printf("Error applying message edit for %s: %v", originalID, result.Error)
+	}
+}
+
+// applyMessageRevoke soft-deletes the message a REVOKE protocol message
+// references, so it drops out of chat listings without losing the row.
+func applyMessageRevoke(protocol *waProto.ProtocolMessage) {
+	targetID := protocol.GetKey().GetID()
+	now := time.Now()
+
+	result := db.Model(&Message{}).Where("id = ?", targetID).Update("deleted_at", &now)
+	if result.Error != nil {
+		log.Printf("Error applying message revoke for %s: %v", targetID, result.Error)
+	}
+}
+
+// ensureChat makes sure a Chat row exists for jid, without clobbering a
+// name that was already synced in from group metadata or a contact.
+func ensureChat(jid types.JID, isGroup bool) {
+	chat := Chat{JID: jid.String(), IsGroup: isGroup}
+	db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "jid"}},
+		DoNothing: true,
+	}).Create(&chat)
+}
+
+// syncContacts mirrors the whatsmeow device store's contact list into
+// Postgres so chat listings can show a name without touching sqlstore.
+func syncContacts(session *Session) {
+	contacts, err := session.Client.Store.Contacts.GetAllContacts(context.Background())
+	if err != nil {
+		log.Printf("Error loading contacts: %v", err)
+		return
+	}
+
+	for jid, info := range contacts {
+		contact := Contact{
+			JID:          jid.String(),
+			PushName:     info.PushName,
+			BusinessName: info.BusinessName,
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "jid"}},
+			UpdateAll: true,
+		}).Create(&contact).Error; err != nil {
+			log.Printf("Error syncing contact %s: %v", jid, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// Chat tracks a conversation thread (1:1 or group) whatsmeow has seen a
+// message for, keyed by its JID rather than the relative insert order of
+// the old string-timestamp schema.
+type Chat struct {
+	JID     string `gorm:"primaryKey"`
+	Name    string
+	IsGroup bool `gorm:"not null;default:false"`
+}
+
+// Contact mirrors an entry from client.Store.Contacts into Postgres so chat
+// listings can show a display name without round-tripping through the
+// whatsmeow device store.
+type Contact struct {
+	JID          string `gorm:"primaryKey"`
+	PushName     string
+	BusinessName string
+}
+
+// Message is keyed by whatsmeow's own message ID instead of an
+// autoincrement column, since that ID is what an edit, a revocation or a
+// quoted reply all reference back to.
+type Message struct {
+	ID              string    `gorm:"primaryKey"`
+	ChatJID         string    `gorm:"not null;index"`
+	SenderJID       string    `gorm:"not null"`
+	Timestamp       time.Time `gorm:"not null;index"`
+	Body            string
+	MediaPath       string
+	QuotedMessageID string
+	EditOfID        string
+	DeletedAt       *time.Time
+}
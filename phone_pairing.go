@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
+)
+
+// e164Pattern matches a phone number in E.164 form, e.g. "+628123456789".
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+type pairPhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+// pairPhone lets a headless deployment link a device without ever
+// rendering a QR code: the user types the returned 8-character code into
+// WhatsApp's "Link with phone number" flow instead of scanning.
+func pairPhone(c *gin.Context) {
+	var req pairPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if !e164Pattern.MatchString(req.Phone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phone must be in E.164 format, e.g. +628123456789"})
+		return
+	}
+
+	session := currentSession(c)
+
+	if session.Client.Store.ID != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "already logged in", "jid": session.Client.Store.ID.String()})
+		return
+	}
+
+	if !session.Client.IsConnected() {
+		if err := session.Client.Connect(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to connect: %v", err)})
+			return
+		}
+	}
+
+	code, err := session.Client.PairPhone(context.Background(), req.Phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to request pairing code: %v", err)})
+		return
+	}
+
+	// The actual link confirmation arrives later as *events.PairSuccess /
+	// *events.PairError through handleEvent, which broadcasts it over /ws.
+	c.JSON(http.StatusOK, gin.H{"pairing_code": code})
+}
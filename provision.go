@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenContextKey = "token"
+
+// authMiddleware checks two independent credentials, since they answer two
+// different questions: the Authorization bearer secret gates whether the
+// caller may use this bridge at all, while X-Session-Token names which
+// user's session the request operates on. Comparing a caller-chosen value
+// against the shared secret directly would let any caller's session token
+// double as the bridge-wide password, so the two are never the same field.
+// requireBridgeSharedSecret enforces that BRIDGE_SHARED_SECRET is set, since
+// authMiddleware has no other way to gate access: X-Session-Token is a
+// caller-chosen map key, not a credential, so without the shared secret
+// every session on the bridge would be readable/driveable by any caller.
+func requireBridgeSharedSecret() string {
+	secret := os.Getenv("BRIDGE_SHARED_SECRET")
+	if secret == "" {
+		log.Fatal("BRIDGE_SHARED_SECRET must be set; it is the only credential gating access to every session on this bridge")
+	}
+	return secret
+}
+
+func authMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if strings.TrimPrefix(header, "Bearer ") != secret {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid Authorization header"})
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader("X-Session-Token")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing X-Session-Token header"})
+			c.Abort()
+			return
+		}
+
+		c.Set(tokenContextKey, token)
+		c.Next()
+	}
+}
+
+func tokenFromContext(c *gin.Context) string {
+	return c.GetString(tokenContextKey)
+}
+
+// currentSession resolves the session for this request's token, creating a
+// fresh (unpaired) device on first use so /provision/v1/login has something
+// to drive.
+func currentSession(c *gin.Context) *Session {
+	token := tokenFromContext(c)
+	if s, ok := sessionManager.Get(token); ok {
+		return s
+	}
+	return sessionManager.NewDevice(token)
+}
+
+// provisionLogin makes sure a (possibly fresh) session exists for this
+// token and points the caller at the WebSocket that actually streams the QR
+// lifecycle, since QR codes are one-shot and don't fit a single JSON reply.
+func provisionLogin(c *gin.Context) {
+	session := currentSession(c)
+
+	if session.Client.Store.ID != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "already logged in", "jid": session.Client.Store.ID.String()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "connect to /ws/login with the same X-Session-Token header to scan the QR code"})
+}
+
+func provisionLogout(c *gin.Context) {
+	token := tokenFromContext(c)
+	session, ok := sessionManager.Get(token)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no session for this token"})
+		return
+	}
+
+	if err := session.Client.Logout(context.Background()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to logout: %v", err)})
+		return
+	}
+
+	db.Where("token = ?", token).Delete(&UserSession{})
+	sessionManager.Delete(token)
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func provisionPing(c *gin.Context) {
+	token := tokenFromContext(c)
+	session, ok := sessionManager.Get(token)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"logged_in": false, "connected": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logged_in": session.Client.Store.ID != nil,
+		"connected": session.Client.IsConnected(),
+	})
+}
+
+func provisionDeleteSession(c *gin.Context) {
+	token := tokenFromContext(c)
+	session, ok := sessionManager.Get(token)
+	if ok {
+		session.Client.Disconnect()
+		if session.Device != nil {
+			_ = session.Device.Delete(context.Background())
+		}
+	}
+
+	db.Where("token = ?", token).Delete(&UserSession{})
+	sessionManager.Delete(token)
+
+	c.JSON(http.StatusOK, gin.H{"message": "session deleted"})
+}
+
+func provisionReconnect(c *gin.Context) {
+	token := tokenFromContext(c)
+	session, ok := sessionManager.Get(token)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no session for this token"})
+		return
+	}
+
+	if session.Client.IsConnected() {
+		c.JSON(http.StatusOK, gin.H{"message": "already connected"})
+		return
+	}
+
+	if err := session.Client.Connect(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to reconnect: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reconnected"})
+}
+
+// persistSession records token -> JID once a device finishes pairing, so
+// SessionManager.Restore can reattach it after a bridge restart.
+func persistSession(token string, jid string) {
+	db.Save(&UserSession{Token: token, JID: jid, CreatedAt: time.Now().Unix()})
+}
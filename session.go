@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"gorm.io/gorm"
+)
+
+// UserSession maps an API token to the whatsmeow device it owns, so the
+// session can be restored after a restart without losing the pairing.
+type UserSession struct {
+	Token     string `gorm:"primaryKey"`
+	JID       string `gorm:"not null"`
+	CreatedAt int64
+}
+
+// Session bundles a live whatsmeow client with the token that owns it.
+type Session struct {
+	Token  string
+	Client *whatsmeow.Client
+	Device *store.Device
+}
+
+// SessionManager keeps one whatsmeow client per API token, all backed by a
+// shared Postgres device store so multiple bridge instances can see the
+// same sessions.
+type SessionManager struct {
+	container *sqlstore.Container
+
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewSessionManager(container *sqlstore.Container) *SessionManager {
+	return &SessionManager{
+		container: container,
+		sessions:  make(map[string]*Session),
+	}
+}
+
+// Get returns the already-running session for a token, if any.
+func (m *SessionManager) Get(token string) (*Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[token]
+	return s, ok
+}
+
+// NewDevice allocates a brand new (unpaired) device for token and registers
+// a client for it. Used the first time a token starts a login flow.
+func (m *SessionManager) NewDevice(token string) *Session {
+	device := m.container.NewDevice()
+	s := m.register(token, device)
+
+	return s
+}
+
+// Restore loads every token -> JID mapping from gorm and reattaches a
+// whatsmeow client to each device, so existing logins survive a restart.
+func (m *SessionManager) Restore(gdb *gorm.DB) error {
+	var rows []UserSession
+	if err := gdb.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		jid, err := parseDeviceJID(row.JID)
+		if err != nil {
+			continue
+		}
+
+		device, err := m.container.GetDevice(context.Background(), jid)
+		if err != nil || device == nil {
+			continue
+		}
+
+		m.register(row.Token, device)
+	}
+
+	return nil
+}
+
+func (m *SessionManager) register(token string, device *store.Device) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cli := whatsmeow.NewClient(device, waLog.Stdout("Client", "INFO", true))
+	cli.AddEventHandler(func(evt interface{}) {
+		handleEvent(token, evt)
+	})
+
+	s := &Session{Token: token, Client: cli, Device: device}
+	m.sessions[token] = s
+	return s
+}
+
+// Delete tears down the in-memory client for token. The caller is
+// responsible for logging out / removing the device store row first.
+func (m *SessionManager) Delete(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+}
+
+func parseDeviceJID(raw string) (types.JID, error) {
+	return types.ParseJID(raw)
+}